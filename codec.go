@@ -0,0 +1,81 @@
+package levelq
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+)
+
+// Codec defines the encoding used by EnqueueObject and DequeueObject to
+// convert between Go values and the byte slices stored in LevelDB.
+// Implementing Codec lets callers plug in an encoding other than the
+// built-in GobCodec, JSONCodec, or RawCodec -- for example protobuf or
+// msgpack.
+type Codec interface {
+	// Marshal encodes value into a byte slice suitable for storage.
+	Marshal(value interface{}) ([]byte, error)
+	// Unmarshal decodes data into the value pointed to by valueIface.
+	Unmarshal(data []byte, valueIface interface{}) error
+}
+
+// ErrRawCodecType is returned by RawCodec when the value passed to
+// Marshal is not a []byte, or the value passed to Unmarshal is not a
+// *[]byte.
+var ErrRawCodecType = errors.New("levelq: RawCodec requires a []byte value")
+
+// GobCodec encodes values using encoding/gob. It is the codec used by
+// OpenQueue.
+type GobCodec struct{}
+
+// Marshal encodes value using encoding/gob.
+func (GobCodec) Marshal(value interface{}) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(value); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// Unmarshal decodes data using encoding/gob into valueIface.
+func (GobCodec) Unmarshal(data []byte, valueIface interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(valueIface)
+}
+
+// JSONCodec encodes values using encoding/json, making queue payloads
+// readable by non-Go consumers.
+type JSONCodec struct{}
+
+// Marshal encodes value using encoding/json.
+func (JSONCodec) Marshal(value interface{}) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+// Unmarshal decodes data using encoding/json into valueIface.
+func (JSONCodec) Unmarshal(data []byte, valueIface interface{}) error {
+	return json.Unmarshal(data, valueIface)
+}
+
+// RawCodec is the identity codec: it stores []byte values unchanged.
+type RawCodec struct{}
+
+// Marshal returns value's bytes unchanged, failing if value is not a
+// []byte.
+func (RawCodec) Marshal(value interface{}) ([]byte, error) {
+	data, ok := value.([]byte)
+	if !ok {
+		return nil, ErrRawCodecType
+	}
+	return data, nil
+}
+
+// Unmarshal sets the []byte pointed to by valueIface to data itself,
+// without copying it, failing if valueIface is not a *[]byte.
+func (RawCodec) Unmarshal(data []byte, valueIface interface{}) error {
+	ptr, ok := valueIface.(*[]byte)
+	if !ok {
+		return ErrRawCodecType
+	}
+	*ptr = data
+	return nil
+}
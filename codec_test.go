@@ -0,0 +1,56 @@
+package levelq
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestQueueJSONCodec(t *testing.T) {
+	file := fmt.Sprintf("test_db_%d", time.Now().UnixNano())
+	q, err := OpenQueueWithOptions(file, &Options{Codec: JSONCodec{}})
+	if err != nil {
+		t.Error(err)
+	}
+	defer q.Drop()
+
+	if err = q.EnqueueObject("value"); err != nil {
+		t.Error(err)
+	}
+
+	var deqItem string
+	if err = q.DequeueObject(&deqItem); err != nil {
+		t.Error(err)
+	}
+
+	if deqItem != "value" {
+		t.Errorf("Expected 'value', got '%s'", deqItem)
+	}
+}
+
+func TestQueueRawCodec(t *testing.T) {
+	file := fmt.Sprintf("test_db_%d", time.Now().UnixNano())
+	q, err := OpenQueueWithOptions(file, &Options{Codec: RawCodec{}})
+	if err != nil {
+		t.Error(err)
+	}
+	defer q.Drop()
+
+	if err = q.EnqueueObject([]byte("value")); err != nil {
+		t.Error(err)
+	}
+
+	var deqItem []byte
+	if err = q.DequeueObject(&deqItem); err != nil {
+		t.Error(err)
+	}
+
+	if string(deqItem) != "value" {
+		t.Errorf("Expected 'value', got '%s'", deqItem)
+	}
+
+	rc := RawCodec{}
+	if _, err = rc.Marshal("not bytes"); err != ErrRawCodecType {
+		t.Errorf("Expected ErrRawCodecType, got %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package levelq
+
+import (
+	"github.com/syndtr/goleveldb/leveldb/filter"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+// Options configures how a Queue is opened. Obtain a base configuration
+// with DefaultOptions and override only the fields you need. The zero
+// value of every tuning field defers to goleveldb's own default.
+type Options struct {
+	// Codec encodes and decodes the values passed to EnqueueObject,
+	// EnqueueObjectBatch, and DequeueObject. It defaults to GobCodec.
+	Codec Codec
+
+	// WriteBuffer is the maximum size, in bytes, of the in-memory write
+	// buffer before it is flushed to disk. Queue workloads are heavily
+	// sequential, so a larger buffer can reduce compaction overhead.
+	WriteBuffer int
+
+	// BlockCacheCapacity is the capacity, in bytes, of the block cache.
+	// Set it to a negative value to disable the block cache entirely.
+	BlockCacheCapacity int
+
+	// BloomFilterBitsPerKey, if greater than zero, enables a bloom
+	// filter with the given bits-per-key, speeding up getValueByID and
+	// Peek lookups at the cost of extra memory.
+	BloomFilterBitsPerKey int
+
+	// Compression selects the block compression algorithm. It defaults
+	// to opt.DefaultCompression, which is snappy.
+	Compression opt.Compression
+
+	// ReadOnly opens the queue's database in read-only mode, for
+	// inspection tools that should never mutate it.
+	ReadOnly bool
+}
+
+// DefaultOptions returns the Options used by OpenQueue.
+func DefaultOptions() *Options {
+	return &Options{
+		Codec: GobCodec{},
+	}
+}
+
+// toLevelDBOptions converts opts to the opt.Options accepted by
+// leveldb.OpenFile.
+func (opts *Options) toLevelDBOptions() *opt.Options {
+	ldbOpts := &opt.Options{
+		WriteBuffer:        opts.WriteBuffer,
+		BlockCacheCapacity: opts.BlockCacheCapacity,
+		Compression:        opts.Compression,
+		ReadOnly:           opts.ReadOnly,
+	}
+
+	if opts.BloomFilterBitsPerKey > 0 {
+		ldbOpts.Filter = filter.NewBloomFilter(opts.BloomFilterBitsPerKey)
+	}
+
+	return ldbOpts
+}
@@ -0,0 +1,69 @@
+package levelq
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+func TestQueueTuningOptions(t *testing.T) {
+	file := fmt.Sprintf("test_db_%d", time.Now().UnixNano())
+	q, err := OpenQueueWithOptions(file, &Options{
+		Codec:                 GobCodec{},
+		WriteBuffer:           4 << 20,
+		BlockCacheCapacity:    8 << 20,
+		BloomFilterBitsPerKey: 10,
+		Compression:           opt.NoCompression,
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	defer q.Drop()
+
+	if err = q.EnqueueObject("value"); err != nil {
+		t.Error(err)
+	}
+
+	if _, err = q.Stats(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQueueReadOnly(t *testing.T) {
+	file := fmt.Sprintf("test_db_%d", time.Now().UnixNano())
+	q, err := OpenQueue(file)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if err = q.EnqueueObject("value"); err != nil {
+		t.Error(err)
+	}
+
+	if err = q.Close(); err != nil {
+		t.Error(err)
+	}
+
+	ro, err := OpenQueueWithOptions(file, &Options{Codec: GobCodec{}, ReadOnly: true})
+	if err != nil {
+		t.Error(err)
+	}
+	defer ro.Drop()
+
+	data, err := ro.Peek()
+	if err != nil {
+		t.Error(err)
+	}
+
+	gc := GobCodec{}
+	var value string
+	if err = gc.Unmarshal(data, &value); err != nil {
+		t.Error(err)
+	}
+
+	if value != "value" {
+		t.Errorf("Expected 'value', got '%s'", value)
+	}
+}
@@ -0,0 +1,318 @@
+package levelq
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"os"
+	"sync"
+
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// Order determines which priority level DequeueObject favors on a
+// PriorityQueue when more than one level has items queued.
+type Order uint8
+
+const (
+	// OrderHighestFirst dequeues from the highest priority level that
+	// currently has items queued.
+	OrderHighestFirst Order = iota
+	// OrderLowestFirst dequeues from the lowest priority level that
+	// currently has items queued.
+	OrderLowestFirst
+)
+
+// PriorityQueue is a FIFO queue with up to 256 distinct priority levels,
+// backed by the same LevelDB ring buffer scheme as Queue. Items within a
+// single priority level are dequeued in FIFO order; across levels, the
+// level chosen is determined by Order.
+type PriorityQueue struct {
+	sync.RWMutex
+	DataDir string
+	db      *leveldb.DB
+	order   Order
+	heads   map[uint8]uint64
+	tails   map[uint8]uint64
+	isOpen  bool
+}
+
+// OpenPriorityQueue opens a priority queue if one exists at the given
+// directory. If one does not already exist, a new priority queue is
+// created. order controls which priority level DequeueObject favors when
+// multiple levels have items queued.
+//
+// Unlike Queue, PriorityQueue does not yet support the Codec/Options
+// abstractions (EnqueueObject/DequeueObject always use GobCodec, and
+// the underlying leveldb.DB is always opened with default options);
+// bringing it in line with Queue is left as future work.
+func OpenPriorityQueue(dataDir string, order Order) (*PriorityQueue, error) {
+	var err error
+
+	// Create a new PriorityQueue.
+	pq := &PriorityQueue{
+		DataDir: dataDir,
+		db:      &leveldb.DB{},
+		order:   order,
+		heads:   make(map[uint8]uint64),
+		tails:   make(map[uint8]uint64),
+		isOpen:  false,
+	}
+
+	// Open database for the priority queue.
+	pq.db, err = leveldb.OpenFile(dataDir, nil)
+	if err != nil {
+		return pq, err
+	}
+
+	// Set isOpen and return.
+	pq.isOpen = true
+	return pq, pq.init()
+}
+
+// priorityIDToKey converts and returns the given priority and ID to a
+// key. Keys sort first by priority, then by ID, so that LevelDB's
+// ordered iteration yields entries grouped and ordered by priority.
+func priorityIDToKey(priority uint8, id uint64) []byte {
+	key := make([]byte, 9)
+	key[0] = priority
+	binary.BigEndian.PutUint64(key[1:], id)
+	return key
+}
+
+// keyToPriorityID converts and returns the given key to a priority and
+// ID.
+func keyToPriorityID(key []byte) (uint8, uint64) {
+	return key[0], binary.BigEndian.Uint64(key[1:])
+}
+
+// orderedPriorities returns all 256 priority levels in the order this
+// queue should check them when dequeuing.
+func orderedPriorities(order Order) []uint8 {
+	priorities := make([]uint8, 256)
+	for i := range priorities {
+		if order == OrderLowestFirst {
+			priorities[i] = uint8(i)
+		} else {
+			priorities[i] = uint8(255 - i)
+		}
+	}
+	return priorities
+}
+
+// enqueue adds a value to the given priority level.
+func (pq *PriorityQueue) enqueue(priority uint8, value []byte) error {
+	pq.Lock()
+	defer pq.Unlock()
+
+	// Check if queue is closed.
+	if !pq.isOpen {
+		return ErrDBClosed
+	}
+
+	// We explicitly wrap the integer index around
+	// so that way if Go changes the overflow behavior,
+	// this code will still work.
+	nextID := (pq.tails[priority] + 1) % MaxQueueSize
+
+	if nextID == pq.heads[priority] {
+		return ErrFull
+	}
+
+	key := priorityIDToKey(priority, nextID)
+
+	// Add it to the queue.
+	if err := pq.db.Put(key, value, nil); err != nil {
+		return err
+	}
+
+	// Increment the tail position for this priority level.
+	pq.tails[priority] = nextID
+
+	return nil
+}
+
+// EnqueueObject is a helper function for enqueue that accepts any value
+// type, which is then encoded into a byte slice using encoding/gob, and
+// queues it at the given priority level.
+func (pq *PriorityQueue) EnqueueObject(priority uint8, value interface{}) error {
+	var buffer bytes.Buffer
+	enc := gob.NewEncoder(&buffer)
+	if err := enc.Encode(value); err != nil {
+		return err
+	}
+	return pq.enqueue(priority, buffer.Bytes())
+}
+
+// dequeue removes and returns the next value in the queue, pulling from
+// the first priority level with items queued in the order given by
+// Order.
+func (pq *PriorityQueue) dequeue() ([]byte, error) {
+	pq.Lock()
+	defer pq.Unlock()
+
+	// Check if queue is closed.
+	if !pq.isOpen {
+		return nil, ErrDBClosed
+	}
+
+	for _, priority := range orderedPriorities(pq.order) {
+		if pq.heads[priority] == pq.tails[priority] {
+			continue
+		}
+
+		nextID := (pq.heads[priority] + 1) % MaxQueueSize
+		key := priorityIDToKey(priority, nextID)
+
+		value, err := pq.db.Get(key, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := pq.db.Delete(key, nil); err != nil {
+			return nil, err
+		}
+
+		pq.heads[priority] = nextID
+
+		return value, nil
+	}
+
+	return nil, ErrEmpty
+}
+
+// DequeueObject removes the next value in the queue, favoring the
+// priority level selected by Order, and deserializes the gob encoded
+// value.
+//
+// The value passed to this method should be a pointer to a variable of
+// the type you wish to decode into. The variable pointed to will hold
+// the decoded object.
+func (pq *PriorityQueue) DequeueObject(valueIface interface{}) error {
+	value, err := pq.dequeue()
+	if err != nil {
+		return err
+	}
+	dec := gob.NewDecoder(bytes.NewReader(value))
+	return dec.Decode(valueIface)
+}
+
+// PeekByPriority decodes, without removing it, the next value queued at
+// the given priority level into valueIface.
+func (pq *PriorityQueue) PeekByPriority(priority uint8, valueIface interface{}) error {
+	pq.RLock()
+	defer pq.RUnlock()
+
+	// Check if queue is closed.
+	if !pq.isOpen {
+		return ErrDBClosed
+	}
+
+	if pq.heads[priority] == pq.tails[priority] {
+		return ErrEmpty
+	}
+
+	nextID := (pq.heads[priority] + 1) % MaxQueueSize
+	key := priorityIDToKey(priority, nextID)
+
+	value, err := pq.db.Get(key, nil)
+	if err != nil {
+		return err
+	}
+
+	dec := gob.NewDecoder(bytes.NewReader(value))
+	return dec.Decode(valueIface)
+}
+
+// Length returns the total number of values queued across all priority
+// levels.
+func (pq *PriorityQueue) Length() uint64 {
+	pq.RLock()
+	defer pq.RUnlock()
+
+	var length uint64
+	for priority, tail := range pq.tails {
+		length += (MaxQueueSize + tail - pq.heads[priority]) % MaxQueueSize
+	}
+	return length
+}
+
+// Close closes the LevelDB database of the priority queue.
+func (pq *PriorityQueue) Close() error {
+	pq.Lock()
+	defer pq.Unlock()
+
+	// Check if queue is already closed.
+	if !pq.isOpen {
+		return nil
+	}
+
+	// Close the LevelDB database.
+	if err := pq.db.Close(); err != nil {
+		return err
+	}
+
+	// Reset per-level heads and tails and set isOpen to false.
+	pq.heads = make(map[uint8]uint64)
+	pq.tails = make(map[uint8]uint64)
+	pq.isOpen = false
+
+	return nil
+}
+
+// Drop closes and deletes the LevelDB database of the priority queue.
+func (pq *PriorityQueue) Drop() error {
+	if err := pq.Close(); err != nil {
+		return err
+	}
+
+	return os.RemoveAll(pq.DataDir)
+}
+
+// init rebuilds the head and tail of every priority level by scanning
+// the first and last key within that level's key prefix.
+func (pq *PriorityQueue) init() error {
+	iter := pq.db.NewIterator(nil, nil)
+	defer iter.Release()
+
+	for priority := 0; priority < 256; priority++ {
+		p := uint8(priority)
+
+		// Set this level's head to the ID immediately before its
+		// first queued key. Computed modulo MaxQueueSize so a first
+		// queued ID of 0 wraps to MaxQueueSize-1 instead of underflowing,
+		// mirroring the fix applied to Queue.init().
+		if iter.Seek([]byte{p}) && iter.Key()[0] == p {
+			_, id := keyToPriorityID(iter.Key())
+			pq.heads[p] = (id + MaxQueueSize - 1) % MaxQueueSize
+		}
+
+		// Set this level's tail to the ID of its last queued key. The
+		// highest priority level has no next-level prefix to seek to,
+		// so fall back to the last key in the whole database.
+		if p == 255 {
+			if iter.Last() && iter.Key()[0] == p {
+				_, id := keyToPriorityID(iter.Key())
+				pq.tails[p] = id
+			}
+			continue
+		}
+
+		// If no key at or past the next level's prefix exists (this
+		// level's data is the last thing in the database), Seek lands
+		// past the end and returns false, so Prev must instead start
+		// from the last key in the whole database.
+		found := iter.Seek([]byte{p + 1})
+		if found {
+			found = iter.Prev()
+		} else {
+			found = iter.Last()
+		}
+		if found && iter.Key()[0] == p {
+			_, id := keyToPriorityID(iter.Key())
+			pq.tails[p] = id
+		}
+	}
+
+	return iter.Error()
+}
@@ -0,0 +1,173 @@
+package levelq
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPriorityQueueEnqueueDequeue(t *testing.T) {
+	file := fmt.Sprintf("test_db_%d", time.Now().UnixNano())
+	pq, err := OpenPriorityQueue(file, OrderHighestFirst)
+	if err != nil {
+		t.Error(err)
+	}
+	defer pq.Drop()
+
+	if err = pq.EnqueueObject(1, "low priority"); err != nil {
+		t.Error(err)
+	}
+
+	if err = pq.EnqueueObject(5, "high priority"); err != nil {
+		t.Error(err)
+	}
+
+	if pq.Length() != 2 {
+		t.Errorf("Expected queue length of 2, got %d", pq.Length())
+	}
+
+	var deqItem string
+
+	if err = pq.DequeueObject(&deqItem); err != nil {
+		t.Error(err)
+	}
+
+	if deqItem != "high priority" {
+		t.Errorf("Expected 'high priority' to be dequeued first, got '%s'", deqItem)
+	}
+
+	if err = pq.DequeueObject(&deqItem); err != nil {
+		t.Error(err)
+	}
+
+	if deqItem != "low priority" {
+		t.Errorf("Expected 'low priority' to be dequeued second, got '%s'", deqItem)
+	}
+}
+
+func TestPriorityQueueInitRecoversFirstIDZero(t *testing.T) {
+	oldMaxQueueSize := MaxQueueSize
+	MaxQueueSize = 5
+	defer func() {
+		MaxQueueSize = oldMaxQueueSize
+	}()
+
+	file := fmt.Sprintf("test_db_%d", time.Now().UnixNano())
+	pq, err := OpenPriorityQueue(file, OrderHighestFirst)
+	if err != nil {
+		t.Error(err)
+	}
+	defer pq.Drop()
+
+	// Cycle IDs 1 through MaxQueueSize-1 through the level so that the
+	// next item enqueued wraps around to ID 0, and init() must recover
+	// its head without underflowing.
+	for i := uint64(0); i < MaxQueueSize-1; i++ {
+		var deq bool
+		if err = pq.EnqueueObject(3, true); err != nil {
+			t.Error(err)
+		}
+		if err = pq.DequeueObject(&deq); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if err = pq.EnqueueObject(3, "value"); err != nil {
+		t.Error(err)
+	}
+
+	if err = pq.Close(); err != nil {
+		t.Error(err)
+	}
+
+	reopened, err := OpenPriorityQueue(file, OrderHighestFirst)
+	if err != nil {
+		t.Error(err)
+	}
+	defer reopened.Close()
+
+	if reopened.Length() != 1 {
+		t.Errorf("Expected queue length of 1, got %d", reopened.Length())
+	}
+
+	var deqItem string
+	if err = reopened.DequeueObject(&deqItem); err != nil {
+		t.Error(err)
+	}
+
+	if deqItem != "value" {
+		t.Errorf("Expected 'value', got '%s'", deqItem)
+	}
+}
+
+func TestPriorityQueueLowestFirst(t *testing.T) {
+	file := fmt.Sprintf("test_db_%d", time.Now().UnixNano())
+	pq, err := OpenPriorityQueue(file, OrderLowestFirst)
+	if err != nil {
+		t.Error(err)
+	}
+	defer pq.Drop()
+
+	if err = pq.EnqueueObject(5, "high priority"); err != nil {
+		t.Error(err)
+	}
+
+	if err = pq.EnqueueObject(1, "low priority"); err != nil {
+		t.Error(err)
+	}
+
+	var deqItem string
+
+	if err = pq.DequeueObject(&deqItem); err != nil {
+		t.Error(err)
+	}
+
+	if deqItem != "low priority" {
+		t.Errorf("Expected 'low priority' to be dequeued first, got '%s'", deqItem)
+	}
+}
+
+func TestPriorityQueuePeekByPriority(t *testing.T) {
+	file := fmt.Sprintf("test_db_%d", time.Now().UnixNano())
+	pq, err := OpenPriorityQueue(file, OrderHighestFirst)
+	if err != nil {
+		t.Error(err)
+	}
+	defer pq.Drop()
+
+	if err = pq.EnqueueObject(3, "value"); err != nil {
+		t.Error(err)
+	}
+
+	var peeked string
+
+	if err = pq.PeekByPriority(3, &peeked); err != nil {
+		t.Error(err)
+	}
+
+	if peeked != "value" {
+		t.Errorf("Expected peeked value to be 'value', got '%s'", peeked)
+	}
+
+	if pq.Length() != 1 {
+		t.Errorf("Expected Peek to not remove the item, queue length is %d", pq.Length())
+	}
+
+	if err = pq.PeekByPriority(7, &peeked); err != ErrEmpty {
+		t.Errorf("Expected empty error for unused priority level, got %v", err)
+	}
+}
+
+func TestPriorityQueueEmpty(t *testing.T) {
+	file := fmt.Sprintf("test_db_%d", time.Now().UnixNano())
+	pq, err := OpenPriorityQueue(file, OrderHighestFirst)
+	if err != nil {
+		t.Error(err)
+	}
+	defer pq.Drop()
+
+	var deqItem string
+	if err = pq.DequeueObject(&deqItem); err != ErrEmpty {
+		t.Errorf("Expected to get empty error, got %v", err)
+	}
+}
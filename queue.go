@@ -6,9 +6,8 @@ package levelq
 // https://github.com/beeker1121/goque/blob/4044bc29b28064db4f08e947c4972d5ca3e0f3c8/LICENSE.
 
 import (
-	"bytes"
+	"context"
 	"math"
-	"encoding/gob"
 	"encoding/binary"
 	"os"
 	"sync"
@@ -28,10 +27,15 @@ var ErrDBClosed = errors.New("levelq: Database is closed")
 // ErrEmpty is returned when the queue is empty.
 var ErrEmpty = errors.New("levelq: Queue is empty")
 
-// ErrFull is returned when the queue is full. 
+// ErrFull is returned when the queue is full.
 // This error should almost never be thrown.
 var ErrFull = errors.New("levelq: Queue is full")
 
+// ErrQueueCorrupt is returned by init() when the keys on disk can't be
+// reconciled into a single run or a clean head/tail wraparound, e.g.
+// because a stray orphan key is present. Call Repair() to recover.
+var ErrQueueCorrupt = errors.New("levelq: queue data is corrupt or ambiguous, run Repair")
+
 // MaxQueueSize is the largest the Queue can grow to
 // The value math.MaxUint64 >> 2 is used in order 
 // to prevent overflow when the size calculation is performed
@@ -43,27 +47,48 @@ type Queue struct {
 	sync.RWMutex
 	DataDir string
 	db      *leveldb.DB
+	codec   Codec
+	cond    *sync.Cond
 	head    uint64
 	tail    uint64
 	isOpen  bool
 }
 
-// OpenQueue opens a queue if one exists at the given directory. If one
-// does not already exist, a new queue is created.
+// OpenQueue opens a queue if one exists at the given directory, using
+// DefaultOptions. If one does not already exist, a new queue is
+// created.
 func OpenQueue(dataDir string) (*Queue, error) {
+	return OpenQueueWithOptions(dataDir, nil)
+}
+
+// OpenQueueWithOptions opens a queue if one exists at the given
+// directory, using the given Options. If one does not already exist, a
+// new queue is created. A nil opts is equivalent to DefaultOptions.
+func OpenQueueWithOptions(dataDir string, opts *Options) (*Queue, error) {
 	var err error
 
+	if opts == nil {
+		opts = DefaultOptions()
+	}
+
+	codec := opts.Codec
+	if codec == nil {
+		codec = GobCodec{}
+	}
+
 	// Create a new Queue.
 	q := &Queue{
 		DataDir: dataDir,
 		db:      &leveldb.DB{},
+		codec:   codec,
 		head:    0,
 		tail:    0,
 		isOpen:  false,
 	}
+	q.cond = sync.NewCond(&q.RWMutex)
 
 	// Open database for the queue.
-	q.db, err = leveldb.OpenFile(dataDir, nil)
+	q.db, err = leveldb.OpenFile(dataDir, opts.toLevelDBOptions())
 	if err != nil {
 		return q, err
 	}
@@ -114,20 +139,81 @@ func (q *Queue) enqueue(value []byte) error {
 	// Increment tail position.
 	q.tail = nextID
 
+	// Wake any goroutines blocked in DequeueBlocking.
+	q.cond.Broadcast()
+
 	return nil
 }
 
 
 // EnqueueObject is a helper function for Enqueue that accepts any
-// value type, which is then encoded into a byte slice using
-// encoding/gob.
+// value type, which is then encoded into a byte slice using the
+// queue's Codec.
 func (q *Queue) EnqueueObject(value interface{}) error {
-	var buffer bytes.Buffer
-	enc := gob.NewEncoder(&buffer)
-	if err := enc.Encode(value); err != nil {
+	data, err := q.codec.Marshal(value)
+	if err != nil {
 		return err
 	}
-	return q.enqueue(buffer.Bytes())
+	return q.enqueue(data)
+}
+
+// enqueueBatch adds all of the given values to the queue as a single
+// atomic LevelDB batch, advancing tail only once the batch has been
+// written successfully.
+func (q *Queue) enqueueBatch(values [][]byte) error {
+	q.Lock()
+	defer q.Unlock()
+
+	// Check if queue is closed.
+	if !q.isOpen {
+		return ErrDBClosed
+	}
+
+	batch := new(leveldb.Batch)
+	nextID := q.tail
+
+	for _, value := range values {
+		nextID = (nextID + 1) % MaxQueueSize
+		if nextID == q.head {
+			return ErrFull
+		}
+		batch.Put(idToKey(nextID), value)
+	}
+
+	if err := q.db.Write(batch, nil); err != nil {
+		return err
+	}
+
+	q.tail = nextID
+
+	// Wake any goroutines blocked in DequeueBlocking.
+	q.cond.Broadcast()
+
+	return nil
+}
+
+// EnqueueBatch adds all of the given values to the queue as a single
+// atomic write, which is dramatically faster than enqueuing values one
+// at a time.
+func (q *Queue) EnqueueBatch(values [][]byte) error {
+	return q.enqueueBatch(values)
+}
+
+// EnqueueObjectBatch is a helper function for EnqueueBatch that accepts
+// any value type, each of which is encoded into a byte slice using the
+// queue's Codec before being written as a single atomic batch.
+func (q *Queue) EnqueueObjectBatch(values []interface{}) error {
+	encoded := make([][]byte, len(values))
+
+	for i, value := range values {
+		data, err := q.codec.Marshal(value)
+		if err != nil {
+			return err
+		}
+		encoded[i] = data
+	}
+
+	return q.enqueueBatch(encoded)
 }
 
 // dequeue removes the next value in the queue and returns it.
@@ -165,21 +251,144 @@ func (q *Queue) dequeue() ([]byte, error) {
 }
 
 // DequeueObject removes the next value in the queue and deserializes
-// the gob encoded value.
+// it using the queue's Codec.
 //
 // The value passed to this method should be a pointer to a variable
 // of the type you wish to decode into. The variable pointed to will
-// hold the decoded object. 
+// hold the decoded object.
 func (q *Queue) DequeueObject(valueIface interface{}) error {
 	value, err := q.dequeue()
 	if err != nil {
 		return err
 	}
-	dec := gob.NewDecoder(bytes.NewReader(value))
-	if err := dec.Decode(valueIface); err != nil {
+	return q.codec.Unmarshal(value, valueIface)
+}
+
+// DequeueBlocking removes and returns the next value in the queue,
+// blocking until one is available, ctx is cancelled, or the queue is
+// closed. It replaces polling on ErrEmpty with a condition variable
+// signalled by enqueue, making it suitable as a work-queue primitive for
+// background workers.
+func (q *Queue) DequeueBlocking(ctx context.Context) ([]byte, error) {
+	// cond.Wait only wakes on a Signal/Broadcast from enqueue or Close,
+	// so wake it ourselves once ctx is done. ctxDone is set under the
+	// same lock that guards the Wait loop's condition, so a broadcast
+	// that races ahead of our call to Wait below (e.g. ctx is already
+	// cancelled) can't be missed the way it would be if we only relied
+	// on the Broadcast itself.
+	var ctxDone bool
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			q.Lock()
+			ctxDone = true
+			q.cond.Broadcast()
+			q.Unlock()
+		case <-stop:
+		}
+	}()
+
+	q.Lock()
+	defer q.Unlock()
+
+	for q.isOpen && q.head == q.tail && !ctxDone {
+		q.cond.Wait()
+	}
+
+	if ctxDone {
+		return nil, ctx.Err()
+	}
+
+	// Check if queue was closed while waiting.
+	if !q.isOpen {
+		return nil, ErrDBClosed
+	}
+
+	// We explicitly wrap the integer index around
+	// so that way if Go changes the overflow behavior,
+	// this code will still work.
+	nextID := (q.head + 1) % MaxQueueSize
+
+	value, err := q.getValueByID(nextID)
+	if err != nil {
+		return nil, err
+	}
+
+	key := idToKey(nextID)
+
+	// Remove this value from the queue.
+	if err := q.db.Delete(key, nil); err != nil {
+		return nil, err
+	}
+
+	// Increment head position.
+	q.head = nextID
+
+	return value, nil
+}
+
+// DequeueObjectContext removes the next value in the queue, blocking
+// until one is available or ctx is cancelled, and deserializes it using
+// the queue's Codec.
+//
+// The value passed to this method should be a pointer to a variable of
+// the type you wish to decode into. The variable pointed to will hold
+// the decoded object.
+func (q *Queue) DequeueObjectContext(ctx context.Context, valueIface interface{}) error {
+	value, err := q.DequeueBlocking(ctx)
+	if err != nil {
 		return err
 	}
-	return nil
+	return q.codec.Unmarshal(value, valueIface)
+}
+
+// DequeueBatch removes up to n values from the front of the queue as a
+// single atomic LevelDB batch, advancing head only once the batch has
+// been written successfully. It returns fewer than n values if the
+// queue does not contain that many.
+func (q *Queue) DequeueBatch(n int) ([][]byte, error) {
+	q.Lock()
+	defer q.Unlock()
+
+	// Check if queue is closed.
+	if !q.isOpen {
+		return nil, ErrDBClosed
+	}
+
+	length := (MaxQueueSize + q.tail - q.head) % MaxQueueSize
+	if length == 0 {
+		return nil, ErrEmpty
+	}
+	if uint64(n) > length {
+		n = int(length)
+	}
+
+	batch := new(leveldb.Batch)
+	values := make([][]byte, 0, n)
+	nextID := q.head
+
+	// Look each ID up in ring order rather than iterating the keyspace:
+	// once the ring has wrapped, the queued IDs are not in key order.
+	for len(values) < n {
+		nextID = (nextID + 1) % MaxQueueSize
+		key := idToKey(nextID)
+		value, err := q.db.Get(key, nil)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		batch.Delete(key)
+	}
+
+	if err := q.db.Write(batch, nil); err != nil {
+		return nil, err
+	}
+
+	q.head = nextID
+
+	return values, nil
 }
 
 
@@ -188,6 +397,26 @@ func (q *Queue) Length() uint64 {
 	return (MaxQueueSize + q.tail - q.head) % MaxQueueSize
 }
 
+// Stats returns statistics about the queue's underlying LevelDB
+// database, such as per-level sizes, compaction counts, and write-stall
+// durations, so operators can observe its behavior.
+func (q *Queue) Stats() (leveldb.DBStats, error) {
+	q.RLock()
+	defer q.RUnlock()
+
+	// Check if queue is closed.
+	if !q.isOpen {
+		return leveldb.DBStats{}, ErrDBClosed
+	}
+
+	var stats leveldb.DBStats
+	if err := q.db.Stats(&stats); err != nil {
+		return leveldb.DBStats{}, err
+	}
+
+	return stats, nil
+}
+
 // Close closes the LevelDB database of the queue.
 func (q *Queue) Close() error {
 	q.Lock()
@@ -209,6 +438,10 @@ func (q *Queue) Close() error {
 	q.tail = 0
 	q.isOpen = false
 
+	// Wake any goroutines blocked in DequeueBlocking so they can
+	// observe that the queue is now closed.
+	q.cond.Broadcast()
+
 	return nil
 }
 
@@ -221,23 +454,71 @@ func (q *Queue) Drop() error {
 	return os.RemoveAll(q.DataDir)
 }
 
+// Peek returns, without removing it, the next value in the queue.
+func (q *Queue) Peek() ([]byte, error) {
+	q.RLock()
+	defer q.RUnlock()
+
+	// Check if queue is closed.
+	if !q.isOpen {
+		return nil, ErrDBClosed
+	}
+
+	return q.getValueByID((q.head + 1) % MaxQueueSize)
+}
+
+// PeekByOffset returns, without removing it, the value offset positions
+// ahead of the front of the queue. An offset of 0 behaves the same as
+// Peek.
+func (q *Queue) PeekByOffset(offset uint64) ([]byte, error) {
+	q.RLock()
+	defer q.RUnlock()
+
+	// Check if queue is closed.
+	if !q.isOpen {
+		return nil, ErrDBClosed
+	}
+
+	if offset >= q.Length() {
+		return nil, ErrOutOfBounds
+	}
+
+	return q.getValueByID((q.head + 1 + offset) % MaxQueueSize)
+}
+
+// NewSnapshot returns a QueueSnapshot wrapping a stable, point-in-time
+// view of the queue's contents that producers may keep enqueuing into
+// without affecting. The caller must call Release on the returned
+// snapshot when done with it.
+func (q *Queue) NewSnapshot() (*QueueSnapshot, error) {
+	q.RLock()
+	defer q.RUnlock()
+
+	// Check if queue is closed.
+	if !q.isOpen {
+		return nil, ErrDBClosed
+	}
+
+	snapshot, err := q.db.GetSnapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	return &QueueSnapshot{
+		snapshot: snapshot,
+		head:     q.head,
+		tail:     q.tail,
+		cursor:   q.head,
+	}, nil
+}
+
 // getValueByID returns an value, if found, for the given ID.
 func (q *Queue) getValueByID(id uint64) ([]byte, error) {
 	// Check if empty or out of bounds.
 	if q.Length() == 0 {
 		return nil, ErrEmpty
-	} else if q.head < q.tail {
-		// in this case neither head nor tail has
-		// wrapped around
-		if id <= q.head || id > q.tail {
-			return nil, ErrOutOfBounds
-		}
-	} else if q.tail < q.head {
-		// in this case tail has wrapped around
-		// but head has not
-		if id > q.tail && id <= q.head {
-			return nil, ErrOutOfBounds
-		}
+	} else if !q.idInRange(id) {
+		return nil, ErrOutOfBounds
 	}
 
 	// Get value from database.
@@ -251,21 +532,117 @@ func (q *Queue) getValueByID(id uint64) ([]byte, error) {
 	return value, nil
 }
 
-// init initializes the queue data.
-func (q *Queue) init() error {
-	// Create a new LevelDB Iterator.
+// idInRange reports whether id falls within (head, tail], the set of
+// IDs currently queued, accounting for wraparound.
+func (q *Queue) idInRange(id uint64) bool {
+	if q.head == q.tail {
+		return false
+	} else if q.head < q.tail {
+		// Neither head nor tail has wrapped around.
+		return id > q.head && id <= q.tail
+	}
+	// tail has wrapped around but head has not.
+	return id > q.head || id <= q.tail
+}
+
+// queueRun is a maximal run of contiguous IDs found on disk.
+type queueRun struct {
+	start, end uint64
+}
+
+// count returns the number of IDs in the run.
+func (r queueRun) count() uint64 {
+	return r.end - r.start + 1
+}
+
+// scanQueueRuns walks every key in the database in order and groups them
+// into maximal runs of contiguous IDs.
+func (q *Queue) scanQueueRuns() ([]queueRun, error) {
 	iter := q.db.NewIterator(nil, nil)
 	defer iter.Release()
 
-	// Set queue head to the first id
-	if iter.First() {
-		q.head = keyToID(iter.Key()) - 1
+	var runs []queueRun
+
+	if !iter.First() {
+		return runs, iter.Error()
+	}
+
+	start := keyToID(iter.Key())
+	prevID := start
+
+	for iter.Next() {
+		id := keyToID(iter.Key())
+		if id != prevID+1 {
+			runs = append(runs, queueRun{start: start, end: prevID})
+			start = id
+		}
+		prevID = id
+	}
+	runs = append(runs, queueRun{start: start, end: prevID})
+
+	return runs, iter.Error()
+}
+
+// reconcileQueueRuns derives a head/tail from the runs found by
+// scanQueueRuns. Keys are stored in ring order, so on-disk IDs are
+// contiguous modulo MaxQueueSize unless the ring has wrapped around, in
+// which case they appear on disk as two contiguous runs: the IDs
+// enqueued since the wrap, starting at the lowest key (runs[0]), and the
+// older IDs enqueued before the wrap and not yet dequeued, ending at the
+// highest key (runs[1]).
+//
+// A stray key left outside the queue's valid range - e.g. by a crash
+// between a batch write and a head/tail update - scans as a second run
+// too, indistinguishable from a genuine wrap by gap position alone. So
+// rather than trust any two runs as a wrap, reconcileQueueRuns checks
+// that they account for every key on disk; if that check fails, or more
+// than two runs are present, clean is false and keep selects only the
+// single largest run as the queue's real data, on the assumption that
+// orphaned keys are a small anomaly alongside the legitimate backlog.
+func reconcileQueueRuns(runs []queueRun) (head, tail uint64, keep []int, clean bool) {
+	if len(runs) == 0 {
+		return 0, 0, nil, true
+	}
+
+	if len(runs) == 2 {
+		tail = runs[0].end
+		head = (runs[1].start + MaxQueueSize - 1) % MaxQueueSize
+		total := runs[0].count() + runs[1].count()
+		if (MaxQueueSize+tail-head)%MaxQueueSize == total {
+			return head, tail, []int{0, 1}, true
+		}
+	}
+
+	best := 0
+	for i, r := range runs {
+		if r.count() > runs[best].count() {
+			best = i
+		}
 	}
 
-	// Set queue tail to the last id
-	if iter.Last() {
-		q.tail = keyToID(iter.Key())
+	head = (runs[best].start + MaxQueueSize - 1) % MaxQueueSize
+	tail = runs[best].end
+
+	return head, tail, []int{best}, len(runs) == 1
+}
+
+// init recovers the queue's head and tail from the keys already present
+// in the database. It bails out with ErrQueueCorrupt, leaving head and
+// tail untouched, rather than silently adopting a bogus head/tail it
+// isn't confident in; call Repair() to recover in that case.
+func (q *Queue) init() error {
+	runs, err := q.scanQueueRuns()
+	if err != nil {
+		return err
+	}
+
+	head, tail, _, clean := reconcileQueueRuns(runs)
+	if !clean {
+		return ErrQueueCorrupt
 	}
 
-	return iter.Error()
+	q.head = head
+	q.tail = tail
+
+	return nil
 }
@@ -1,12 +1,21 @@
 package levelq
 
 import (
+	"bytes"
+	"context"
+	"encoding/gob"
 	"fmt"
 	"os"
 	"testing"
 	"time"
 )
 
+// decodeGob is a test helper for decoding the raw gob-encoded bytes
+// returned by Peek, PeekByOffset, and QueueSnapshot.
+func decodeGob(value []byte, valueIface interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(value)).Decode(valueIface)
+}
+
 func TestQueueClose(t *testing.T) {
 	file := fmt.Sprintf("test_db_%d", time.Now().UnixNano())
 	q, err := OpenQueue(file)
@@ -136,6 +145,282 @@ func TestQueueEmpty(t *testing.T) {
 	}
 }
 
+func TestQueueEnqueueBatch(t *testing.T) {
+	file := fmt.Sprintf("test_db_%d", time.Now().UnixNano())
+	q, err := OpenQueue(file)
+	if err != nil {
+		t.Error(err)
+	}
+	defer q.Drop()
+
+	values := make([]interface{}, 10)
+	for i := range values {
+		values[i] = fmt.Sprintf("value for item %d", i+1)
+	}
+
+	if err = q.EnqueueObjectBatch(values); err != nil {
+		t.Error(err)
+	}
+
+	if q.Length() != 10 {
+		t.Errorf("Expected queue size of 10, got %d", q.Length())
+	}
+
+	var deqItem string
+	if err = q.DequeueObject(&deqItem); err != nil {
+		t.Error(err)
+	}
+
+	if deqItem != "value for item 1" {
+		t.Errorf("Expected string to be 'value for item 1', got '%s'", deqItem)
+	}
+}
+
+func TestQueueDequeueBatch(t *testing.T) {
+	file := fmt.Sprintf("test_db_%d", time.Now().UnixNano())
+	q, err := OpenQueue(file)
+	if err != nil {
+		t.Error(err)
+	}
+	defer q.Drop()
+
+	for i := 1; i <= 10; i++ {
+		if err = q.EnqueueObject(fmt.Sprintf("value for item %d", i)); err != nil {
+			t.Error(err)
+		}
+	}
+
+	values, err := q.DequeueBatch(4)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(values) != 4 {
+		t.Errorf("Expected to dequeue 4 values, got %d", len(values))
+	}
+
+	if q.Length() != 6 {
+		t.Errorf("Expected queue length of 6, got %d", q.Length())
+	}
+
+	// Requesting more than what remains should return only what's left.
+	values, err = q.DequeueBatch(100)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(values) != 6 {
+		t.Errorf("Expected to dequeue remaining 6 values, got %d", len(values))
+	}
+
+	if _, err = q.DequeueBatch(1); err != ErrEmpty {
+		t.Errorf("Expected to get empty error, got %v", err)
+	}
+}
+
+func TestQueuePeek(t *testing.T) {
+	file := fmt.Sprintf("test_db_%d", time.Now().UnixNano())
+	q, err := OpenQueue(file)
+	if err != nil {
+		t.Error(err)
+	}
+	defer q.Drop()
+
+	for i := 1; i <= 3; i++ {
+		if err = q.EnqueueObject(fmt.Sprintf("value for item %d", i)); err != nil {
+			t.Error(err)
+		}
+	}
+
+	value, err := q.Peek()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if q.Length() != 3 {
+		t.Errorf("Expected Peek to not remove the item, queue length is %d", q.Length())
+	}
+
+	var peeked string
+	if err = decodeGob(value, &peeked); err != nil {
+		t.Error(err)
+	}
+
+	if peeked != "value for item 1" {
+		t.Errorf("Expected peeked value to be 'value for item 1', got '%s'", peeked)
+	}
+
+	value, err = q.PeekByOffset(2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if err = decodeGob(value, &peeked); err != nil {
+		t.Error(err)
+	}
+
+	if peeked != "value for item 3" {
+		t.Errorf("Expected peeked value to be 'value for item 3', got '%s'", peeked)
+	}
+
+	if _, err = q.PeekByOffset(3); err != ErrOutOfBounds {
+		t.Errorf("Expected out of bounds error, got %v", err)
+	}
+}
+
+func TestQueueSnapshot(t *testing.T) {
+	file := fmt.Sprintf("test_db_%d", time.Now().UnixNano())
+	q, err := OpenQueue(file)
+	if err != nil {
+		t.Error(err)
+	}
+	defer q.Drop()
+
+	for i := 1; i <= 3; i++ {
+		if err = q.EnqueueObject(fmt.Sprintf("value for item %d", i)); err != nil {
+			t.Error(err)
+		}
+	}
+
+	snap, err := q.NewSnapshot()
+	if err != nil {
+		t.Error(err)
+	}
+	defer snap.Release()
+
+	if err = q.EnqueueObject("value for item 4"); err != nil {
+		t.Error(err)
+	}
+
+	if snap.Length() != 3 {
+		t.Errorf("Expected snapshot length of 3, got %d", snap.Length())
+	}
+
+	var items []string
+	for {
+		value, ok := snap.Next()
+		if !ok {
+			break
+		}
+		var item string
+		if err = decodeGob(value, &item); err != nil {
+			t.Error(err)
+		}
+		items = append(items, item)
+	}
+
+	if len(items) != 3 {
+		t.Errorf("Expected to iterate 3 items, got %d", len(items))
+	}
+}
+
+func TestQueueDequeueBlocking(t *testing.T) {
+	file := fmt.Sprintf("test_db_%d", time.Now().UnixNano())
+	q, err := OpenQueue(file)
+	if err != nil {
+		t.Error(err)
+	}
+	defer q.Drop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		var value string
+		errCh <- q.DequeueObjectContext(context.Background(), &value)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	if err = q.EnqueueObject("value"); err != nil {
+		t.Error(err)
+	}
+
+	select {
+	case err = <-errCh:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected DequeueObjectContext to return once a value was enqueued")
+	}
+}
+
+func TestQueueDequeueBlockingContextCancel(t *testing.T) {
+	file := fmt.Sprintf("test_db_%d", time.Now().UnixNano())
+	q, err := OpenQueue(file)
+	if err != nil {
+		t.Error(err)
+	}
+	defer q.Drop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err = q.DequeueBlocking(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestQueueDequeueBlockingAlreadyCancelled(t *testing.T) {
+	file := fmt.Sprintf("test_db_%d", time.Now().UnixNano())
+	q, err := OpenQueue(file)
+	if err != nil {
+		t.Error(err)
+	}
+	defer q.Drop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// An already-cancelled context must not be able to race ahead of
+	// cond.Wait and leave DequeueBlocking hung forever.
+	for i := 0; i < 2000; i++ {
+		done := make(chan error, 1)
+		go func() {
+			_, err := q.DequeueBlocking(ctx)
+			done <- err
+		}()
+
+		select {
+		case err := <-done:
+			if err != context.Canceled {
+				t.Errorf("Expected context.Canceled, got %v", err)
+			}
+		case <-time.After(200 * time.Millisecond):
+			t.Fatalf("DequeueBlocking hung on iteration %d", i)
+		}
+	}
+}
+
+func TestQueueDequeueBlockingClose(t *testing.T) {
+	file := fmt.Sprintf("test_db_%d", time.Now().UnixNano())
+	q, err := OpenQueue(file)
+	if err != nil {
+		t.Error(err)
+	}
+	defer os.RemoveAll(file)
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := q.DequeueBlocking(context.Background())
+		errCh <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	if err = q.Close(); err != nil {
+		t.Error(err)
+	}
+
+	select {
+	case err = <-errCh:
+		if err != ErrDBClosed {
+			t.Errorf("Expected ErrDBClosed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected DequeueBlocking to return once the queue was closed")
+	}
+}
+
 func TestQueueWrap(t *testing.T) {
 	//Hack MaxQueueSize so we aren't waiting forever
 	oldMaxQueueSize := MaxQueueSize
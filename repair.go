@@ -0,0 +1,77 @@
+package levelq
+
+import (
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// RepairReport summarizes the result of a Repair call.
+type RepairReport struct {
+	// Head and Tail are the head and tail positions recovered while
+	// repairing the queue.
+	Head uint64
+	Tail uint64
+
+	// OrphanedKeys is the number of keys found outside (head, tail]
+	// and deleted.
+	OrphanedKeys int
+}
+
+// Repair re-derives the queue's head and tail directly from the
+// keyspace, deleting any keys that don't belong to the run(s) it settles
+// on, and returns a report describing what was found. Unlike init(),
+// which bails out with ErrQueueCorrupt rather than guess, Repair is the
+// explicit recovery path: when the keyspace can't be reconciled into a
+// clean wraparound - e.g. because a stray orphan key was left behind by
+// a crash between a batch write and a subsequent head/tail update -
+// Repair keeps the single largest contiguous run as the queue's real
+// data and deletes everything else.
+//
+// Repair does not trust the queue's in-memory head/tail: if init()
+// already bailed out with ErrQueueCorrupt when the queue was opened,
+// those fields were never set, so Repair must scan the keyspace itself
+// rather than build on top of them.
+func (q *Queue) Repair() (RepairReport, error) {
+	q.Lock()
+	defer q.Unlock()
+
+	// Check if queue is closed.
+	if !q.isOpen {
+		return RepairReport{}, ErrDBClosed
+	}
+
+	runs, err := q.scanQueueRuns()
+	if err != nil {
+		return RepairReport{}, err
+	}
+
+	head, tail, keep, _ := reconcileQueueRuns(runs)
+
+	kept := make(map[int]bool, len(keep))
+	for _, i := range keep {
+		kept[i] = true
+	}
+
+	report := RepairReport{Head: head, Tail: tail}
+
+	batch := new(leveldb.Batch)
+	for i, r := range runs {
+		if kept[i] {
+			continue
+		}
+		for id := r.start; id <= r.end; id++ {
+			batch.Delete(idToKey(id))
+			report.OrphanedKeys++
+		}
+	}
+
+	if report.OrphanedKeys > 0 {
+		if err := q.db.Write(batch, nil); err != nil {
+			return RepairReport{}, err
+		}
+	}
+
+	q.head = head
+	q.tail = tail
+
+	return report, nil
+}
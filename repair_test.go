@@ -0,0 +1,170 @@
+package levelq
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestQueueInitRecoversAfterWrap(t *testing.T) {
+	oldMaxQueueSize := MaxQueueSize
+	MaxQueueSize = 5
+	defer func() {
+		MaxQueueSize = oldMaxQueueSize
+	}()
+
+	file := fmt.Sprintf("test_db_%d", time.Now().UnixNano())
+	q, err := OpenQueue(file)
+	if err != nil {
+		t.Error(err)
+	}
+	defer q.Drop()
+
+	// Enqueue and dequeue enough to bring the ring up to its last slot,
+	// then leave a couple of items queued that straddle the wrap.
+	for i := 0; i < int(MaxQueueSize)-2; i++ {
+		var deq bool
+		if err = q.EnqueueObject(true); err != nil {
+			t.Error(err)
+		}
+		if err = q.DequeueObject(&deq); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if err = q.EnqueueObject("a"); err != nil {
+		t.Error(err)
+	}
+	if err = q.EnqueueObject("b"); err != nil {
+		t.Error(err)
+	}
+
+	wantHead, wantTail := q.head, q.tail
+
+	if err = q.Close(); err != nil {
+		t.Error(err)
+	}
+
+	reopened, err := OpenQueue(file)
+	if err != nil {
+		t.Error(err)
+	}
+	defer reopened.Close()
+
+	if reopened.head != wantHead || reopened.tail != wantTail {
+		t.Errorf("Expected recovered (head, tail) == (%d, %d), got (%d, %d)",
+			wantHead, wantTail, reopened.head, reopened.tail)
+	}
+
+	var a, b string
+	if err = reopened.DequeueObject(&a); err != nil {
+		t.Error(err)
+	}
+	if err = reopened.DequeueObject(&b); err != nil {
+		t.Error(err)
+	}
+
+	if a != "a" || b != "b" {
+		t.Errorf("Expected to recover items in FIFO order 'a', 'b', got '%s', '%s'", a, b)
+	}
+}
+
+func TestQueueRepairRemovesOrphans(t *testing.T) {
+	file := fmt.Sprintf("test_db_%d", time.Now().UnixNano())
+	q, err := OpenQueue(file)
+	if err != nil {
+		t.Error(err)
+	}
+	defer q.Drop()
+
+	for i := 1; i <= 3; i++ {
+		if err = q.EnqueueObject(fmt.Sprintf("value for item %d", i)); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// Simulate a key left behind outside of (head, tail], e.g. by a
+	// crash between a batch write and a head/tail update.
+	orphanID := (q.tail + 50) % MaxQueueSize
+	if err = q.db.Put(idToKey(orphanID), []byte("orphan"), nil); err != nil {
+		t.Error(err)
+	}
+
+	report, err := q.Repair()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if report.OrphanedKeys != 1 {
+		t.Errorf("Expected to find 1 orphaned key, found %d", report.OrphanedKeys)
+	}
+
+	if q.Length() != 3 {
+		t.Errorf("Expected queue length of 3 after repair, got %d", q.Length())
+	}
+
+	if _, err = q.db.Get(idToKey(orphanID), nil); err == nil {
+		t.Error("Expected orphaned key to have been deleted")
+	}
+}
+
+func TestQueueRepairRecoversAfterCorruptReopen(t *testing.T) {
+	file := fmt.Sprintf("test_db_%d", time.Now().UnixNano())
+	q, err := OpenQueue(file)
+	if err != nil {
+		t.Error(err)
+	}
+	defer q.Drop()
+
+	for i := 1; i <= 3; i++ {
+		if err = q.EnqueueObject(fmt.Sprintf("value for item %d", i)); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// Simulate a key left behind outside of (head, tail], e.g. by a
+	// crash between a batch write and a head/tail update, and close
+	// before repairing so init() has to face it fresh on reopen.
+	orphanID := (q.tail + 50) % MaxQueueSize
+	if err = q.db.Put(idToKey(orphanID), []byte("orphan"), nil); err != nil {
+		t.Error(err)
+	}
+
+	if err = q.Close(); err != nil {
+		t.Error(err)
+	}
+
+	reopened, err := OpenQueue(file)
+	if err != ErrQueueCorrupt {
+		t.Errorf("Expected ErrQueueCorrupt, got %v", err)
+	}
+	defer reopened.Close()
+
+	report, err := reopened.Repair()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if report.OrphanedKeys != 1 {
+		t.Errorf("Expected to find 1 orphaned key, found %d", report.OrphanedKeys)
+	}
+
+	if reopened.Length() != 3 {
+		t.Errorf("Expected queue length of 3 after repair, got %d", reopened.Length())
+	}
+
+	if _, err = reopened.db.Get(idToKey(orphanID), nil); err == nil {
+		t.Error("Expected orphaned key to have been deleted")
+	}
+
+	for i := 1; i <= 3; i++ {
+		var value string
+		if err = reopened.DequeueObject(&value); err != nil {
+			t.Error(err)
+		}
+
+		if want := fmt.Sprintf("value for item %d", i); value != want {
+			t.Errorf("Expected '%s', got '%s'", want, value)
+		}
+	}
+}
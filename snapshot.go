@@ -0,0 +1,57 @@
+package levelq
+
+import (
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// QueueSnapshot is a stable, non-consuming view of a Queue's contents as
+// of the moment NewSnapshot was called. It is backed by a LevelDB
+// snapshot, so it is unaffected by enqueues or dequeues performed on the
+// originating Queue afterward.
+type QueueSnapshot struct {
+	snapshot *leveldb.Snapshot
+	head     uint64
+	tail     uint64
+	cursor   uint64
+}
+
+// Length returns the total number of values present in the snapshot.
+func (s *QueueSnapshot) Length() uint64 {
+	return (MaxQueueSize + s.tail - s.head) % MaxQueueSize
+}
+
+// Peek returns, without consuming it, the first value as of when the
+// snapshot was taken.
+func (s *QueueSnapshot) Peek() ([]byte, error) {
+	if s.Length() == 0 {
+		return nil, ErrEmpty
+	}
+
+	return s.snapshot.Get(idToKey((s.head+1)%MaxQueueSize), nil)
+}
+
+// Next returns the next value in the snapshot, walking from head to
+// tail, and a bool reporting whether a value was found. It returns
+// false once every value present as of the snapshot has been returned.
+func (s *QueueSnapshot) Next() ([]byte, bool) {
+	if s.cursor == s.tail {
+		return nil, false
+	}
+
+	nextID := (s.cursor + 1) % MaxQueueSize
+
+	value, err := s.snapshot.Get(idToKey(nextID), nil)
+	if err != nil {
+		return nil, false
+	}
+
+	s.cursor = nextID
+
+	return value, true
+}
+
+// Release releases the underlying LevelDB snapshot. The QueueSnapshot
+// must not be used after calling Release.
+func (s *QueueSnapshot) Release() {
+	s.snapshot.Release()
+}